@@ -0,0 +1,43 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+// vespa deploy command
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/vespa-engine/vespa/client/go/vespa"
+)
+
+func init() {
+	rootCmd.AddCommand(deployCmd)
+	addTargetFlag(deployCmd)
+	addPackageFlags(deployCmd)
+}
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy application-directory-or-zip",
+	Short: "Deploys an application package",
+	Long: `Deploys an application package.
+
+Prepares the application package the same way 'vespa prepare' does - resolving
+preprocess:include directives, ${name} property substitutions and
+environment/region overlays - and reports whether the package is valid. The
+prepared package is not uploaded to the target.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ap, err := vespa.FindApplicationPackage(args[0], true)
+		if err != nil {
+			log.Print(color.Red("Error: "), err)
+			return
+		}
+		pkg, err := ap.Package(packageOptionsFromFlags())
+		if err != nil {
+			log.Print(color.Red("Error: "), err)
+			return
+		}
+		defer pkg.Close()
+		// TODO: Upload pkg to documentTarget()'s deploy endpoint
+		log.Print(color.Green("Success: "), "application package is valid")
+	},
+}
@@ -0,0 +1,72 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+// vespa feed command
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vespa-engine/vespa/client/go/internal/vespa/feed"
+)
+
+var (
+	feedConcurrency int64
+	feedRoute       string
+	feedTimeoutSecs int
+	feedTrace       bool
+)
+
+func init() {
+	rootCmd.AddCommand(feedCmd)
+	addTargetFlag(feedCmd)
+	feedCmd.PersistentFlags().Int64Var(&feedConcurrency, "concurrency", 8, "The maximum number of concurrent feed operations")
+	feedCmd.PersistentFlags().StringVar(&feedRoute, "route", "", "The message bus route to feed through")
+	feedCmd.PersistentFlags().IntVar(&feedTimeoutSecs, "timeout", 30, "Timeout for each feed operation, in seconds")
+	feedCmd.PersistentFlags().BoolVar(&feedTrace, "trace", false, "Print one JSON result line per document as it completes")
+}
+
+var feedCmd = &cobra.Command{
+	Use:   "feed file",
+	Short: "Feeds documents to Vespa",
+	Long: `Feeds documents to Vespa.
+
+Reads put/update/remove operations, as a JSON array or as newline-separated
+JSON (JSONL), from the given file, or from standard input if no file is
+given, and feeds them concurrently to a Vespa endpoint.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		in := os.Stdin
+		if len(args) == 1 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.Print(color.Red("Error: "), err)
+				return
+			}
+			defer f.Close()
+			in = f
+		}
+
+		options := feed.Options{
+			Target:         documentTarget(),
+			Route:          feedRoute,
+			Timeout:        time.Duration(feedTimeoutSecs) * time.Second,
+			MinConcurrency: 1,
+			MaxConcurrency: feedConcurrency,
+		}
+		if feedTrace {
+			options.Results = os.Stdout
+		}
+		feeder := feed.NewFeeder(options)
+		stats, err := feeder.Feed(context.Background(), in)
+		if err != nil {
+			log.Print(color.Red("Error: "), err)
+		}
+		summary := stats.Summary()
+		log.Print(color.Green("Success: "), fmt.Sprintf("fed %d documents, %d errors, %.1f ops/sec",
+			summary.Successes, summary.Errors, summary.ThroughputOps))
+	},
+}
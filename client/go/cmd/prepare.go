@@ -0,0 +1,62 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+// vespa prepare command
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/vespa-engine/vespa/client/go/vespa"
+)
+
+var (
+	packageVars        map[string]string
+	packageEnvironment string
+	packageRegion      string
+)
+
+func addPackageFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringToStringVar(&packageVars, "var", nil, "Set a property substituted for ${name} in services.xml and schema files (key=value)")
+	cmd.PersistentFlags().StringVar(&packageEnvironment, "environment", "", "The environment overlay to resolve in services.xml and hosts.xml")
+	cmd.PersistentFlags().StringVar(&packageRegion, "region", "", "The region overlay to resolve in services.xml and hosts.xml")
+}
+
+func packageOptionsFromFlags() vespa.PackageOptions {
+	return vespa.PackageOptions{
+		Properties:  packageVars,
+		Environment: packageEnvironment,
+		Region:      packageRegion,
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(prepareCmd)
+	addTargetFlag(prepareCmd)
+	addPackageFlags(prepareCmd)
+}
+
+var prepareCmd = &cobra.Command{
+	Use:   "prepare application-directory-or-zip",
+	Short: "Prepares an application package for deployment, resolving preprocessing directives",
+	Long: `Prepares an application package for deployment.
+
+Resolves preprocess:include directives, ${name} property substitutions and
+environment/region overlays in the application package and reports whether the
+package is valid. The prepared package is not written out or uploaded.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ap, err := vespa.FindApplicationPackage(args[0], true)
+		if err != nil {
+			log.Print(color.Red("Error: "), err)
+			return
+		}
+		pkg, err := ap.Package(packageOptionsFromFlags())
+		if err != nil {
+			log.Print(color.Red("Error: "), err)
+			return
+		}
+		defer pkg.Close()
+		log.Print(color.Green("Success: "), "application package is valid")
+		// TODO: Upload the prepared package to the config server's prepare session endpoint
+	},
+}
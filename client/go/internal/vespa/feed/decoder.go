@@ -0,0 +1,80 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder decodes a stream of feed operations, given as either a JSON array or as
+// newline-separated JSON (JSONL) of put/update/remove operations.
+type Decoder struct {
+	br        *bufio.Reader
+	dec       *json.Decoder
+	started   bool
+	arrayMode bool
+}
+
+// NewDecoder returns a Decoder reading operations from r.
+func NewDecoder(r io.Reader) *Decoder {
+	br := bufio.NewReader(r)
+	return &Decoder{br: br, dec: json.NewDecoder(br)}
+}
+
+// Decode returns the next document in the stream, or io.EOF when the stream is exhausted.
+func (d *Decoder) Decode() (Document, error) {
+	if !d.started {
+		d.started = true
+		if err := d.detectArray(); err != nil {
+			return Document{}, err
+		}
+	}
+	if d.arrayMode && !d.dec.More() {
+		d.dec.Token() // consume closing ']'
+		return Document{}, io.EOF
+	}
+	base := d.dec.InputOffset()
+	var doc Document
+	if err := d.dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Document{}, io.EOF
+		}
+		var syntaxErr *json.SyntaxError
+		offset := d.dec.InputOffset()
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		return Document{}, fmt.Errorf("invalid json at byte offset %d: %w", offset-base, err)
+	}
+	if err := parseDocument(&doc); err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
+
+// detectArray peeks past any leading whitespace to determine whether the stream holds a JSON
+// array, consuming its opening '[' token if so.
+func (d *Decoder) detectArray() error {
+	for {
+		b, err := d.br.Peek(1)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.EOF
+			}
+			return err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			d.br.Discard(1)
+		case '[':
+			d.arrayMode = true
+			_, err := d.dec.Token()
+			return err
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+// Document and document ID parsing for the feed package.
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DocumentId represents a parsed Vespa document ID, e.g. id:ns:type:n=123:user.
+type DocumentId struct {
+	Namespace    string
+	Type         string
+	Number       *int64
+	Group        string
+	UserSpecific string
+}
+
+// Equal returns whether d and o identify the same document.
+func (d DocumentId) Equal(o DocumentId) bool {
+	if d.Namespace != o.Namespace || d.Type != o.Type || d.Group != o.Group || d.UserSpecific != o.UserSpecific {
+		return false
+	}
+	if (d.Number == nil) != (o.Number == nil) {
+		return false
+	}
+	return d.Number == nil || *d.Number == *o.Number
+}
+
+// String returns the canonical string form of d.
+func (d DocumentId) String() string {
+	option := ""
+	switch {
+	case d.Number != nil:
+		option = fmt.Sprintf("n=%d", *d.Number)
+	case d.Group != "":
+		option = "g=" + d.Group
+	}
+	return fmt.Sprintf("id:%s:%s:%s:%s", d.Namespace, d.Type, option, d.UserSpecific)
+}
+
+// URLPath returns the /document/v1/... path identifying d.
+func (d DocumentId) URLPath() string {
+	var sb strings.Builder
+	sb.WriteString("/document/v1/")
+	sb.WriteString(url.PathEscape(d.Namespace))
+	sb.WriteString("/")
+	sb.WriteString(url.PathEscape(d.Type))
+	sb.WriteString("/")
+	switch {
+	case d.Number != nil:
+		sb.WriteString("number/")
+		sb.WriteString(strconv.FormatInt(*d.Number, 10))
+	case d.Group != "":
+		sb.WriteString("group/")
+		sb.WriteString(d.Group)
+	default:
+		sb.WriteString("docid")
+	}
+	sb.WriteString("/")
+	sb.WriteString(d.UserSpecific)
+	return sb.String()
+}
+
+// ParseDocumentId parses s into a DocumentId. s must be of the form
+// id:<namespace>:<type>:[n=<number>|g=<group>]:<specific>.
+func ParseDocumentId(s string) (DocumentId, error) {
+	parts := strings.SplitN(s, ":", 5)
+	if len(parts) != 5 || parts[0] != "id" {
+		return DocumentId{}, fmt.Errorf("invalid document id %q: must start with 'id:namespace:type:[key=value]:'", s)
+	}
+	namespace, docType, option, specific := parts[1], parts[2], parts[3], parts[4]
+	if namespace == "" {
+		return DocumentId{}, fmt.Errorf("invalid document id %q: missing namespace", s)
+	}
+	if docType == "" {
+		return DocumentId{}, fmt.Errorf("invalid document id %q: missing document type", s)
+	}
+	if specific == "" {
+		return DocumentId{}, fmt.Errorf("invalid document id %q: missing document specific part", s)
+	}
+	id := DocumentId{Namespace: namespace, Type: docType, UserSpecific: specific}
+	switch {
+	case option == "":
+	case strings.HasPrefix(option, "n="):
+		numString := strings.TrimPrefix(option, "n=")
+		if numString == "" {
+			return DocumentId{}, fmt.Errorf("invalid document id %q: empty number", s)
+		}
+		n, err := strconv.ParseInt(numString, 10, 64)
+		if err != nil {
+			return DocumentId{}, fmt.Errorf("invalid document id %q: invalid number %q", s, numString)
+		}
+		id.Number = &n
+	case strings.HasPrefix(option, "g="):
+		group := strings.TrimPrefix(option, "g=")
+		if group == "" {
+			return DocumentId{}, fmt.Errorf("invalid document id %q: empty group", s)
+		}
+		id.Group = group
+	default:
+		return DocumentId{}, fmt.Errorf("invalid document id %q: invalid option %q", s, option)
+	}
+	return id, nil
+}
+
+// Document represents a single put, update or remove operation, as read from a feed file.
+type Document struct {
+	PutId     string          `json:"put,omitempty"`
+	UpdateId  string          `json:"update,omitempty"`
+	RemoveId  string          `json:"remove,omitempty"`
+	IdString  string          `json:"-"`
+	Condition string          `json:"condition,omitempty"`
+	Create    bool            `json:"create,omitempty"`
+	Fields    json.RawMessage `json:"fields,omitempty"`
+
+	id DocumentId
+}
+
+// parseDocument resolves the document ID referenced by d, in whichever of PutId, UpdateId,
+// RemoveId or IdString is set, and caches the result in d.
+func parseDocument(d *Document) error {
+	s := d.RemoveId
+	if s == "" {
+		s = d.UpdateId
+	}
+	if s == "" {
+		s = d.PutId
+	}
+	if s == "" {
+		s = d.IdString
+	}
+	if s == "" {
+		return fmt.Errorf("document has no id")
+	}
+	id, err := ParseDocumentId(s)
+	if err != nil {
+		return err
+	}
+	d.id = id
+	return nil
+}
+
+// Id returns the parsed document ID of d.
+func (d Document) Id() DocumentId { return d.id }
+
+// FeedURL returns the HTTP method and URL to use for feeding d to baseURL, with extra query
+// parameters from params merged in.
+func (d Document) FeedURL(baseURL string, params url.Values) (string, *url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid base url %q: %w", baseURL, err)
+	}
+	method := "POST"
+	switch {
+	case d.RemoveId != "":
+		method = "DELETE"
+	case d.UpdateId != "":
+		method = "PUT"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + d.id.URLPath()
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	if d.Condition != "" {
+		q.Set("condition", d.Condition)
+	}
+	if d.Create {
+		q.Set("create", "true")
+	}
+	u.RawQuery = q.Encode()
+	return method, u, nil
+}
+
+// Body returns the JSON request body to send for d.
+func (d Document) Body() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"fields":`)
+	if d.Fields == nil {
+		buf.WriteString("null")
+	} else {
+		buf.Write(d.Fields)
+	}
+	buf.WriteString("}")
+	return buf.Bytes()
+}
@@ -0,0 +1,229 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+// Package feed implements decoding and concurrent feeding of documents to a Vespa endpoint.
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HTTPClient is the subset of http.Client used by Feeder. It is an interface so tests can
+// substitute a mock implementation.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Options configures a Feeder.
+type Options struct {
+	// Target is the base URL of the Vespa endpoint to feed to.
+	Target string
+	// Route is an optional message bus route to feed through.
+	Route string
+	// Timeout is the per-request HTTP timeout.
+	Timeout time.Duration
+	// MinConcurrency and MaxConcurrency bound the number of in-flight requests. The actual
+	// concurrency is adapted between these bounds based on observed latency and error rate.
+	MinConcurrency int64
+	MaxConcurrency int64
+	// MaxRetries is the maximum number of retries per operation before giving up.
+	MaxRetries int
+	// Results, if set, receives one JSON-encoded Result per document, in completion order.
+	Results io.Writer
+	// Client is the HTTP client used to send requests. Defaults to http.DefaultClient.
+	Client HTTPClient
+}
+
+func (o Options) withDefaults() Options {
+	if o.MinConcurrency <= 0 {
+		o.MinConcurrency = 1
+	}
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 128
+	}
+	if o.MaxConcurrency < o.MinConcurrency {
+		o.MaxConcurrency = o.MinConcurrency
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 10
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	return o
+}
+
+// Result is the outcome of feeding a single document, as written to Options.Results.
+type Result struct {
+	Id        string `json:"id"`
+	Status    int    `json:"status"`
+	Message   string `json:"message,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Retries   int    `json:"retries"`
+}
+
+// Feeder feeds documents, read from a Decoder, to a Vespa endpoint, one goroutine per document,
+// with the number in flight bounded by an adaptive limit.
+type Feeder struct {
+	options Options
+}
+
+// NewFeeder returns a Feeder configured with options.
+func NewFeeder(options Options) *Feeder {
+	return &Feeder{options: options.withDefaults()}
+}
+
+// Feed decodes and feeds documents from r, blocking until all documents are fed, the reader is
+// exhausted, ctx is done, or the decoder encounters invalid input. It returns aggregated
+// statistics for the operations that were attempted.
+func (f *Feeder) Feed(ctx context.Context, r io.Reader) (*Stats, error) {
+	dec := NewDecoder(r)
+	stats := newStats(time.Now())
+	throttle := newThrottler(f.options.MinConcurrency, f.options.MaxConcurrency)
+	lim := newLimiter()
+	var resultsMu sync.Mutex
+	var wg sync.WaitGroup
+	var decodeErr error
+
+decode:
+	for {
+		select {
+		case <-ctx.Done():
+			decodeErr = ctx.Err()
+			break decode
+		default:
+		}
+		doc, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			decodeErr = err
+			break
+		}
+		lim.acquire(throttle.inflight())
+		wg.Add(1)
+		go func(doc Document) {
+			defer wg.Done()
+			defer lim.release()
+			result := f.feedOne(ctx, doc, throttle)
+			stats.record(result.Status, time.Duration(result.LatencyMs)*time.Millisecond, statusErr(result.Status))
+			if f.options.Results != nil {
+				f.writeResult(&resultsMu, result)
+			}
+		}(doc)
+	}
+	wg.Wait()
+	stats.finish(time.Now())
+	return stats, decodeErr
+}
+
+func statusErr(status int) error {
+	if status == 0 || status >= 400 {
+		return fmt.Errorf("status %d", status)
+	}
+	return nil
+}
+
+func (f *Feeder) writeResult(mu *sync.Mutex, result Result) {
+	mu.Lock()
+	defer mu.Unlock()
+	enc := json.NewEncoder(f.options.Results)
+	enc.Encode(result) // best-effort: a failing writer does not abort the feed
+}
+
+// feedOne sends a single document, retrying on throttling or network errors with exponential
+// backoff, and adjusts throttle based on the outcome.
+func (f *Feeder) feedOne(ctx context.Context, doc Document, throttle *throttler) Result {
+	start := time.Now()
+	params := url.Values{}
+	if f.options.Route != "" {
+		params.Set("route", f.options.Route)
+	}
+	method, u, err := doc.FeedURL(f.options.Target, params)
+	if err != nil {
+		return Result{Id: doc.id.String(), Status: 0, Message: err.Error(), LatencyMs: time.Since(start).Milliseconds()}
+	}
+
+	var status int
+	var message string
+	retries := 0
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, f.options.Timeout)
+		req, err := http.NewRequestWithContext(reqCtx, method, u.String(), bytes.NewReader(doc.Body()))
+		if err != nil {
+			cancel()
+			status, message = 0, err.Error()
+			break
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, doErr := f.options.Client.Do(req)
+		cancel()
+		if doErr == nil {
+			status = resp.StatusCode
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			message = string(body)
+		} else {
+			status, message = 0, doErr.Error()
+		}
+
+		if !retryable(status, doErr) {
+			if doErr == nil {
+				throttle.update(time.Since(start), false)
+			}
+			break
+		}
+		throttle.update(time.Since(start), true)
+		if retries >= f.options.MaxRetries {
+			break
+		}
+		retries++
+		select {
+		case <-ctx.Done():
+			status, message = 0, ctx.Err().Error()
+			return Result{Id: doc.id.String(), Status: status, Message: message, LatencyMs: time.Since(start).Milliseconds(), Retries: retries}
+		case <-time.After(backoff(retries-1, 100*time.Millisecond, 10*time.Second)):
+		}
+	}
+	return Result{Id: doc.id.String(), Status: status, Message: message, LatencyMs: time.Since(start).Milliseconds(), Retries: retries}
+}
+
+// limiter bounds the number of concurrently in-flight operations to a dynamically adjustable
+// limit.
+type limiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	active int64
+}
+
+func newLimiter() *limiter {
+	l := &limiter{}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *limiter) acquire(limit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.cond.Signal()
+}
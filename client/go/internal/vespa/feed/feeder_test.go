@@ -0,0 +1,142 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockClient returns one canned response per call to Do, in order, looping on the last one once
+// exhausted. It is safe for concurrent use.
+type mockClient struct {
+	mu        sync.Mutex
+	responses []int // HTTP status codes; 0 means a network error
+	calls     int
+}
+
+func (c *mockClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := 200
+	if c.calls < len(c.responses) {
+		status = c.responses[c.calls]
+	} else if len(c.responses) > 0 {
+		status = c.responses[len(c.responses)-1]
+	}
+	c.calls++
+	if status == 0 {
+		return nil, errNetwork
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+var errNetwork = &mockError{"connection refused"}
+
+type mockError struct{ s string }
+
+func (e *mockError) Error() string { return e.s }
+
+func TestFeedRetriesOnThrottledResponse(t *testing.T) {
+	client := &mockClient{responses: []int{429, 503, 200}}
+	var results bytes.Buffer
+	feeder := NewFeeder(Options{
+		Target:         "https://example.com",
+		Client:         client,
+		MinConcurrency: 1,
+		MaxConcurrency: 1,
+		MaxRetries:     5,
+		Results:        &results,
+	})
+
+	stats, err := feeder.Feed(context.Background(), strings.NewReader(`{"put": "id:ns:type::doc1", "fields": {}}`))
+	if err != nil {
+		t.Fatalf("Feed() failed: %s", err)
+	}
+	if got := stats.Successes(); got != 1 {
+		t.Errorf("Successes() = %d, want 1", got)
+	}
+	if got := stats.Errors(); got != 0 {
+		t.Errorf("Errors() = %d, want 0", got)
+	}
+	if client.calls != 3 {
+		t.Errorf("client was called %d times, want 3 (2 retries + 1 success)", client.calls)
+	}
+
+	var result Result
+	if err := json.Unmarshal(results.Bytes(), &result); err != nil {
+		t.Fatalf("could not parse result line %q: %s", results.String(), err)
+	}
+	if result.Status != 200 || result.Retries != 2 {
+		t.Errorf("result = %+v, want status 200 and 2 retries", result)
+	}
+}
+
+func TestFeedGivesUpAfterMaxRetries(t *testing.T) {
+	client := &mockClient{responses: []int{503}}
+	feeder := NewFeeder(Options{
+		Target:         "https://example.com",
+		Client:         client,
+		MinConcurrency: 1,
+		MaxConcurrency: 1,
+		MaxRetries:     2,
+	})
+	stats, err := feeder.Feed(context.Background(), strings.NewReader(`{"put": "id:ns:type::doc1", "fields": {}}`))
+	if err != nil {
+		t.Fatalf("Feed() failed: %s", err)
+	}
+	if got := stats.Errors(); got != 1 {
+		t.Errorf("Errors() = %d, want 1", got)
+	}
+	if client.calls != 3 { // initial attempt + 2 retries
+		t.Errorf("client was called %d times, want 3", client.calls)
+	}
+}
+
+func TestFeedRetriesOnNetworkError(t *testing.T) {
+	client := &mockClient{responses: []int{0, 200}}
+	feeder := NewFeeder(Options{
+		Target:         "https://example.com",
+		Client:         client,
+		MinConcurrency: 1,
+		MaxConcurrency: 1,
+		MaxRetries:     5,
+	})
+	stats, err := feeder.Feed(context.Background(), strings.NewReader(`{"put": "id:ns:type::doc1", "fields": {}}`))
+	if err != nil {
+		t.Fatalf("Feed() failed: %s", err)
+	}
+	if got := stats.Successes(); got != 1 {
+		t.Errorf("Successes() = %d, want 1", got)
+	}
+}
+
+func TestFeedDecodesJSONLAndArray(t *testing.T) {
+	client := &mockClient{responses: []int{200}}
+	feeder := NewFeeder(Options{Target: "https://example.com", Client: client, MaxConcurrency: 4})
+	jsonl := "{\"put\": \"id:ns:type::doc1\", \"fields\": {}}\n{\"put\": \"id:ns:type::doc2\", \"fields\": {}}\n"
+	stats, err := feeder.Feed(context.Background(), strings.NewReader(jsonl))
+	if err != nil {
+		t.Fatalf("Feed() failed: %s", err)
+	}
+	if got := stats.Successes(); got != 2 {
+		t.Errorf("Successes() = %d, want 2", got)
+	}
+	if stats.ThroughputPerSecond() < 0 {
+		t.Errorf("ThroughputPerSecond() = %f, want >= 0", stats.ThroughputPerSecond())
+	}
+
+	array := "[{\"put\": \"id:ns:type::doc1\", \"fields\": {}}, {\"put\": \"id:ns:type::doc2\", \"fields\": {}}]"
+	stats, err = feeder.Feed(context.Background(), strings.NewReader(array))
+	if err != nil {
+		t.Fatalf("Feed() of array failed: %s", err)
+	}
+	if got := stats.Successes(); got != 2 {
+		t.Errorf("Successes() of array = %d, want 2", got)
+	}
+}
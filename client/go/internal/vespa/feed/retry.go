@@ -0,0 +1,27 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"math/rand"
+	"time"
+)
+
+// retryable returns whether a response with the given status, or the given error, should be
+// retried: 429 (throttled) and 503 (unavailable) responses, and any network-level error.
+func retryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == 429 || status == 503
+}
+
+// backoff returns the delay to wait before retry number n (starting at 0), using exponential
+// backoff with jitter, capped at max.
+func backoff(n int, base, max time.Duration) time.Duration {
+	d := base << n
+	if d <= 0 || d > max { // guard against overflow from large n
+		d = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(d) + 1))
+	return d/2 + jitter/2
+}
@@ -0,0 +1,126 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats holds aggregated statistics for a feed operation.
+type Stats struct {
+	mu sync.Mutex
+
+	start        time.Time
+	end          time.Time
+	ok           int64
+	errors       int64
+	inflight     int64
+	latencies    []time.Duration
+	statusCounts map[int]int64
+}
+
+// newStats returns an empty Stats with its start time set to now.
+func newStats(now time.Time) *Stats {
+	return &Stats{start: now, statusCounts: make(map[int]int64)}
+}
+
+// record registers the result of a single feed operation.
+func (s *Stats) record(status int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil || status >= 400 {
+		s.errors++
+	} else {
+		s.ok++
+	}
+	s.statusCounts[status]++
+	s.latencies = append(s.latencies, latency)
+}
+
+// finish marks t as the time the feed completed.
+func (s *Stats) finish(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end = t
+}
+
+// Successes returns the number of operations that completed successfully.
+func (s *Stats) Successes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ok
+}
+
+// Errors returns the number of operations that failed.
+func (s *Stats) Errors() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errors
+}
+
+// StatusCounts returns a copy of the number of responses seen, by HTTP status code. A status
+// of 0 indicates a network or other error where no response was received.
+func (s *Stats) StatusCounts() map[int]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[int]int64, len(s.statusCounts))
+	for k, v := range s.statusCounts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// ThroughputPerSecond returns the number of completed operations (successful or not) per
+// second of wall-clock time spent feeding.
+func (s *Stats) ThroughputPerSecond() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(s.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.ok+s.errors) / elapsed
+}
+
+// Percentile returns the p-th percentile (0-100) of observed operation latencies.
+func (s *Stats) Percentile(p float64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// Summary is a point-in-time, JSON-serializable snapshot of a Stats.
+type Summary struct {
+	Successes     int64         `json:"successes"`
+	Errors        int64         `json:"errors"`
+	ThroughputOps float64       `json:"opsPerSecond"`
+	LatencyP50Ms  int64         `json:"latencyP50Ms"`
+	LatencyP95Ms  int64         `json:"latencyP95Ms"`
+	LatencyP99Ms  int64         `json:"latencyP99Ms"`
+	StatusCounts  map[int]int64 `json:"statusCounts"`
+}
+
+// Summary returns a snapshot of s.
+func (s *Stats) Summary() Summary {
+	return Summary{
+		Successes:     s.Successes(),
+		Errors:        s.Errors(),
+		ThroughputOps: s.ThroughputPerSecond(),
+		LatencyP50Ms:  s.Percentile(50).Milliseconds(),
+		LatencyP95Ms:  s.Percentile(95).Milliseconds(),
+		LatencyP99Ms:  s.Percentile(99).Milliseconds(),
+		StatusCounts:  s.StatusCounts(),
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatsPercentiles(t *testing.T) {
+	stats := newStats(time.Now())
+	for ms := 1; ms <= 100; ms++ {
+		stats.record(200, time.Duration(ms)*time.Millisecond, nil)
+	}
+	if got := stats.Percentile(50).Milliseconds(); got != 50 {
+		t.Errorf("Percentile(50) = %dms, want 50ms", got)
+	}
+	if got := stats.Percentile(95).Milliseconds(); got != 95 {
+		t.Errorf("Percentile(95) = %dms, want 95ms", got)
+	}
+	if got := stats.Percentile(99).Milliseconds(); got != 99 {
+		t.Errorf("Percentile(99) = %dms, want 99ms", got)
+	}
+}
+
+func TestStatsSuccessesAndErrors(t *testing.T) {
+	stats := newStats(time.Now())
+	stats.record(200, time.Millisecond, nil)
+	stats.record(200, time.Millisecond, nil)
+	stats.record(500, time.Millisecond, errors.New("status 500"))
+	if got := stats.Successes(); got != 2 {
+		t.Errorf("Successes() = %d, want 2", got)
+	}
+	if got := stats.Errors(); got != 1 {
+		t.Errorf("Errors() = %d, want 1", got)
+	}
+	counts := stats.StatusCounts()
+	if counts[200] != 2 || counts[500] != 1 {
+		t.Errorf("StatusCounts() = %v, want map[200:2 500:1]", counts)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyBackoffFactor is how much higher than the baseline a request's latency must be before
+// it is treated as a sign of congestion, cutting concurrency instead of growing it.
+const latencyBackoffFactor = 2
+
+// throttler adapts the number of in-flight requests a Feeder allows, using an additive-increase
+// multiplicative-decrease (AIMD) strategy: sustained success at a stable latency slowly grows
+// the allowed concurrency, while throttling responses, errors, or a single request whose latency
+// exceeds latencyBackoffFactor times the baseline cut it sharply.
+type throttler struct {
+	mu          sync.Mutex
+	min         int64
+	max         int64
+	current     float64
+	baseLatency time.Duration
+}
+
+func newThrottler(min, max int64) *throttler {
+	return &throttler{min: min, max: max, current: float64(min)}
+}
+
+// inflight returns the number of requests currently allowed to be in flight.
+func (t *throttler) inflight() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(t.current)
+}
+
+// update adjusts the allowed concurrency based on the outcome of a single request: throttled
+// cuts it immediately, otherwise latency is compared against a rolling baseline, cutting when it
+// exceeds latencyBackoffFactor times the baseline and growing otherwise.
+func (t *throttler) update(latency time.Duration, throttled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if throttled {
+		t.cutLocked()
+		return
+	}
+	if t.baseLatency == 0 {
+		t.baseLatency = latency
+		t.growLocked()
+		return
+	}
+	if latency > t.baseLatency*latencyBackoffFactor {
+		t.cutLocked()
+		return
+	}
+	// Track the baseline as an exponential moving average, so it follows gradual, sustained
+	// shifts in latency without reacting to single-request noise.
+	t.baseLatency += (latency - t.baseLatency) / 8
+	t.growLocked()
+}
+
+func (t *throttler) growLocked() {
+	t.current += 1 / t.current
+	if t.current > float64(t.max) {
+		t.current = float64(t.max)
+	}
+}
+
+func (t *throttler) cutLocked() {
+	t.current -= t.current / 2
+	if t.current < float64(t.min) {
+		t.current = float64(t.min)
+	}
+}
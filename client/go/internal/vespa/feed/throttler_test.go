@@ -0,0 +1,71 @@
+// Copyright Verizon Media. Licensed under the terms of the Apache 2.0 license. See LICENSE in the project root.
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottlerGrowsOnSustainedSuccess(t *testing.T) {
+	th := newThrottler(1, 100)
+	if got := th.inflight(); got != 1 {
+		t.Fatalf("inflight() = %d, want 1", got)
+	}
+	for i := 0; i < 50; i++ {
+		th.update(10, false)
+	}
+	if got := th.inflight(); got <= 1 {
+		t.Errorf("inflight() = %d, want > 1 after sustained success", got)
+	}
+}
+
+func TestThrottlerCutsOnThrottledResponse(t *testing.T) {
+	th := newThrottler(1, 100)
+	for i := 0; i < 50; i++ {
+		th.update(10, false)
+	}
+	grown := th.inflight()
+	th.update(10, true)
+	if got := th.inflight(); got >= grown {
+		t.Errorf("inflight() = %d, want < %d after a throttled response", got, grown)
+	}
+}
+
+func TestThrottlerCutsOnLatencySpike(t *testing.T) {
+	th := newThrottler(1, 100)
+	for i := 0; i < 50; i++ {
+		th.update(10, false)
+	}
+	grown := th.inflight()
+	th.update(10*latencyBackoffFactor+1, false)
+	if got := th.inflight(); got >= grown {
+		t.Errorf("inflight() = %d, want < %d after a latency spike", got, grown)
+	}
+}
+
+func TestThrottlerNeverExceedsBounds(t *testing.T) {
+	th := newThrottler(2, 5)
+	for i := 0; i < 1000; i++ {
+		th.update(10, false)
+	}
+	if got := th.inflight(); got > 5 {
+		t.Errorf("inflight() = %d, want <= 5", got)
+	}
+	for i := 0; i < 1000; i++ {
+		th.update(10, true)
+	}
+	if got := th.inflight(); got < 2 {
+		t.Errorf("inflight() = %d, want >= 2", got)
+	}
+}
+
+func TestBackoffStaysWithinBounds(t *testing.T) {
+	base := time.Nanosecond
+	max := 1000 * time.Nanosecond // kept tiny so the test runs instantly
+	for n := 0; n < 10; n++ {
+		d := backoff(n, base, max)
+		if d < 0 || d > max {
+			t.Errorf("backoff(%d, %s, %s) = %s, want in [0, %s]", n, base, max, d, max)
+		}
+	}
+}
@@ -64,64 +64,13 @@ func (ap *ApplicationPackage) IsJava() bool {
 
 func isZip(filename string) bool { return filepath.Ext(filename) == ".zip" }
 
-func zipDir(dir string, destination string) error {
-	if filepath.IsAbs(dir) {
-		message := "Path must be relative, but '" + dir + "'"
-		return errors.New(message)
-	}
-	if !util.PathExists(dir) {
-		message := "'" + dir + "' should be an application package zip or dir, but does not exist"
-		return errors.New(message)
-	}
-	if !util.IsDirectory(dir) {
-		message := "'" + dir + "' should be an application package dir, but is a (non-zip) file"
-		return errors.New(message)
-	}
-
-	file, err := os.Create(destination)
-	if err != nil {
-		message := "Could not create a temporary zip file for the application package: " + err.Error()
-		return errors.New(message)
-	}
-	defer file.Close()
-
-	w := zip.NewWriter(file)
-	defer w.Close()
-
-	walker := func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		zippath, err := filepath.Rel(dir, path)
-		if err != nil {
-			return err
-		}
-		zipfile, err := w.Create(zippath)
-		if err != nil {
-			return err
-		}
-
-		_, err = io.Copy(zipfile, file)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-	return filepath.Walk(dir, walker)
-}
-
-func (ap *ApplicationPackage) zipReader(test bool) (io.ReadCloser, error) {
+// Package returns a reader for the zipped application package, applying any preprocessing
+// directives found in the source directory: XML includes, ${...} property substitution, and
+// environment/region overlays. Pre-built zip files, and directories containing no preprocessing
+// directives, are packaged unchanged. The caller must close the returned reader.
+func (ap *ApplicationPackage) Package(opts PackageOptions) (io.ReadCloser, error) {
 	zipFile := ap.Path
-	if test {
+	if opts.Test {
 		zipFile = ap.TestPath
 	}
 	if !ap.IsZip() {
@@ -134,7 +83,7 @@ func (ap *ApplicationPackage) zipReader(test bool) (io.ReadCloser, error) {
 			os.Remove(tempZip.Name())
 			// TODO: Caller must remove temporary file
 		}()
-		if err := zipDir(zipFile, tempZip.Name()); err != nil {
+		if err := packageDir(zipFile, tempZip.Name(), opts); err != nil {
 			return nil, err
 		}
 		zipFile = tempZip.Name()
@@ -0,0 +1,385 @@
+package vespa
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/vespa-engine/vespa/client/go/util"
+)
+
+// PackageOptions controls preprocessing of an application package directory during packaging.
+type PackageOptions struct {
+	// Test selects the test application package (TestPath) instead of the main one (Path).
+	Test bool
+	// Properties substitute ${name} tokens found in services.xml and schema files. These take
+	// precedence over any same-named property found in properties.xml.
+	Properties map[string]string
+	// Environment and Region, if non-empty, resolve deployment overlays: elements in
+	// services.xml and hosts.xml specifying a non-matching environment or region attribute are
+	// removed, along with their children.
+	Environment string
+	Region      string
+}
+
+var (
+	includeTag      = regexp.MustCompile(`<preprocess:include\s+file="([^"]+)"\s*/>`)
+	xmlProlog       = regexp.MustCompile(`^\s*<\?xml[^?]*\?>\s*`)
+	propertyRef     = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+	environmentAttr = regexp.MustCompile(`\benvironment\s*=\s*"([^"]*)"`)
+	regionAttr      = regexp.MustCompile(`\bregion\s*=\s*"([^"]*)"`)
+)
+
+const maxIncludeDepth = 8
+
+// packageDir zips the application package directory dir into destination, applying any
+// preprocessing directives described by opts.
+func packageDir(dir string, destination string, opts PackageOptions) error {
+	if filepath.IsAbs(dir) {
+		return errors.New("path must be relative, but '" + dir + "'")
+	}
+	if !util.PathExists(dir) {
+		return errors.New("'" + dir + "' should be an application package zip or dir, but does not exist")
+	}
+	if !util.IsDirectory(dir) {
+		return errors.New("'" + dir + "' should be an application package dir, but is a (non-zip) file")
+	}
+
+	properties, err := loadProperties(dir, opts.Properties)
+	if err != nil {
+		return err
+	}
+	preprocessed, excluded, err := preprocessEntryFiles(dir, properties, opts)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("could not create a temporary zip file for the application package: %w", err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	defer w.Close()
+
+	walker := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		zippath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		zippath = filepath.ToSlash(zippath)
+		if excluded[zippath] {
+			return nil
+		}
+
+		zipfile, err := w.Create(zippath)
+		if err != nil {
+			return err
+		}
+		if content, ok := preprocessed[zippath]; ok {
+			_, err = zipfile.Write(content)
+			return err
+		}
+		if filepath.Ext(path) == ".sd" {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			_, err = zipfile.Write(substituteProperties(content, properties, false))
+			return err
+		}
+
+		srcFile, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer srcFile.Close()
+		_, err = io.Copy(zipfile, srcFile)
+		return err
+	}
+	return filepath.Walk(dir, walker)
+}
+
+// preprocessEntryFiles resolves includes, property substitution and environment/region overlays
+// for services.xml and hosts.xml, if present. It returns the resulting content, keyed by
+// zip-slash relative path, along with the set of files (relative to dir, in zip-slash form)
+// that are now inlined into that content and so must not also be packaged standalone.
+func preprocessEntryFiles(dir string, properties map[string]string, opts PackageOptions) (map[string][]byte, map[string]bool, error) {
+	preprocessed := make(map[string][]byte)
+	excluded := map[string]bool{"properties.xml": true}
+	for _, name := range []string{"services.xml", "hosts.xml"} {
+		path := filepath.Join(dir, name)
+		if !util.PathExists(path) {
+			continue
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		content, err = resolveIncludes(dir, dir, content, excluded, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		content = substituteProperties(content, properties, true)
+		content, err = applyEnvironmentOverlay(content, opts.Environment, opts.Region)
+		if err != nil {
+			return nil, nil, err
+		}
+		preprocessed[name] = content
+	}
+	return preprocessed, excluded, nil
+}
+
+// resolveIncludes replaces every <preprocess:include file="..."/> element in content with the
+// contents of the referenced file, resolved relative to baseDir, recursively. Every file inlined
+// this way is recorded, relative to rootDir in zip-slash form, in excluded, so it is not also
+// packaged standalone.
+func resolveIncludes(rootDir, baseDir string, content []byte, excluded map[string]bool, depth int) ([]byte, error) {
+	if depth >= maxIncludeDepth {
+		return nil, fmt.Errorf("preprocess:include nesting exceeds %d levels", maxIncludeDepth)
+	}
+	var resolveErr error
+	resolved := includeTag.ReplaceAllFunc(content, func(match []byte) []byte {
+		if resolveErr != nil {
+			return match
+		}
+		name := string(includeTag.FindSubmatch(match)[1])
+		includePath := filepath.Join(baseDir, name)
+		relPath, err := filepath.Rel(rootDir, includePath)
+		if err != nil {
+			relPath = name
+		}
+		excluded[filepath.ToSlash(relPath)] = true
+		included, err := ioutil.ReadFile(includePath)
+		if err != nil {
+			resolveErr = fmt.Errorf("could not read included file %q: %w", name, err)
+			return match
+		}
+		included, err = resolveIncludes(rootDir, filepath.Dir(includePath), included, excluded, depth+1)
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		return xmlProlog.ReplaceAll(included, nil)
+	})
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	return resolved, nil
+}
+
+// loadProperties reads properties.xml from dir, if present, and merges overrides on top of it.
+func loadProperties(dir string, overrides map[string]string) (map[string]string, error) {
+	properties := make(map[string]string)
+	path := filepath.Join(dir, "properties.xml")
+	if util.PathExists(path) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var doc struct {
+			Properties []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:"value,attr"`
+			} `xml:"property"`
+		}
+		if err := xml.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("could not parse properties.xml: %w", err)
+		}
+		for _, p := range doc.Properties {
+			properties[p.Name] = p.Value
+		}
+	}
+	for name, value := range overrides {
+		properties[name] = value
+	}
+	return properties, nil
+}
+
+// substituteProperties replaces every ${name} token in content with its value in properties.
+// Tokens with no matching property are left untouched. When escapeXML is set, the substituted
+// value is XML-escaped first, so it cannot alter the surrounding document's structure; this
+// must be disabled for non-XML files such as schemas.
+func substituteProperties(content []byte, properties map[string]string, escapeXML bool) []byte {
+	return propertyRef.ReplaceAllFunc(content, func(token []byte) []byte {
+		name := string(propertyRef.FindSubmatch(token)[1])
+		value, ok := properties[name]
+		if !ok {
+			return token
+		}
+		if !escapeXML {
+			return []byte(value)
+		}
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(value))
+		return buf.Bytes()
+	})
+}
+
+// applyEnvironmentOverlay removes elements whose environment or region attribute does not match
+// environment/region, along with their children. Either may be empty to skip that check. This
+// operates directly on the byte stream, rather than through an XML decode/encode round-trip, so
+// everything outside a removed element - the XML prolog, namespace prefixes, comments, and
+// self-closing tags - is preserved exactly as written.
+func applyEnvironmentOverlay(content []byte, environment, region string) ([]byte, error) {
+	if environment == "" && region == "" {
+		return content, nil
+	}
+	if !bytes.Contains(content, []byte("environment=")) && !bytes.Contains(content, []byte("region=")) {
+		return content, nil
+	}
+	var out bytes.Buffer
+	i := 0
+	for i < len(content) {
+		lt := bytes.IndexByte(content[i:], '<')
+		if lt < 0 {
+			out.Write(content[i:])
+			break
+		}
+		lt += i
+		out.Write(content[i:lt])
+
+		if bytes.HasPrefix(content[lt:], []byte("<!--")) {
+			end := bytes.Index(content[lt:], []byte("-->"))
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			end += lt + len("-->")
+			out.Write(content[lt:end])
+			i = end
+			continue
+		}
+		if bytes.HasPrefix(content[lt:], []byte("<?")) || bytes.HasPrefix(content[lt:], []byte("<!")) {
+			end := bytes.IndexByte(content[lt:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated declaration")
+			}
+			end += lt + 1
+			out.Write(content[lt:end])
+			i = end
+			continue
+		}
+
+		gt := bytes.IndexByte(content[lt:], '>')
+		if gt < 0 {
+			return nil, fmt.Errorf("unterminated tag")
+		}
+		gt += lt + 1
+		tag := content[lt:gt]
+
+		if len(tag) > 1 && tag[1] == '/' {
+			out.Write(tag)
+			i = gt
+			continue
+		}
+
+		if overlayMismatch(tag, environment, region) {
+			if isSelfClosingTag(tag) {
+				i = gt
+				continue
+			}
+			end, err := skipElement(content, gt, tagName(tag))
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			continue
+		}
+		out.Write(tag)
+		i = gt
+	}
+	return out.Bytes(), nil
+}
+
+// overlayMismatch reports whether the start tag's environment/region attributes, if present,
+// disagree with the requested environment/region.
+func overlayMismatch(tag []byte, environment, region string) bool {
+	if environment != "" {
+		if m := environmentAttr.FindSubmatch(tag); m != nil && string(m[1]) != environment {
+			return true
+		}
+	}
+	if region != "" {
+		if m := regionAttr.FindSubmatch(tag); m != nil && string(m[1]) != region {
+			return true
+		}
+	}
+	return false
+}
+
+// tagName returns the element name of a start or end tag, e.g. "admin" for "<admin foo=\"bar\">".
+func tagName(tag []byte) string {
+	i := 1
+	if len(tag) > 1 && tag[1] == '/' {
+		i = 2
+	}
+	start := i
+	for i < len(tag) && !isTagNameBoundary(tag[i]) {
+		i++
+	}
+	return string(tag[start:i])
+}
+
+func isTagNameBoundary(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '>' || c == '/'
+}
+
+func isSelfClosingTag(tag []byte) bool {
+	body := bytes.TrimRight(tag[:len(tag)-1], " \t\r\n")
+	return bytes.HasSuffix(body, []byte("/"))
+}
+
+// skipElement scans content from pos, which must be just past the opening tag of name, to the
+// byte offset just past that element's matching closing tag, accounting for nested elements of
+// the same name.
+func skipElement(content []byte, pos int, name string) (int, error) {
+	openTag := []byte("<" + name)
+	closeTag := []byte("</" + name)
+	depth := 1
+	i := pos
+	for {
+		lt := bytes.IndexByte(content[i:], '<')
+		if lt < 0 {
+			return 0, fmt.Errorf("unterminated element <%s>", name)
+		}
+		lt += i
+		if bytes.HasPrefix(content[lt:], []byte("<!--")) {
+			end := bytes.Index(content[lt:], []byte("-->"))
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated comment")
+			}
+			i = lt + end + len("-->")
+			continue
+		}
+		gt := bytes.IndexByte(content[lt:], '>')
+		if gt < 0 {
+			return 0, fmt.Errorf("unterminated tag")
+		}
+		gt += lt + 1
+		tag := content[lt:gt]
+		switch {
+		case bytes.HasPrefix(tag, closeTag) && isTagNameBoundary(tag[len(closeTag)]):
+			depth--
+			if depth == 0 {
+				return gt, nil
+			}
+		case bytes.HasPrefix(tag, openTag) && isTagNameBoundary(tag[len(openTag)]) && !isSelfClosingTag(tag):
+			depth++
+		}
+		i = gt
+	}
+}
@@ -0,0 +1,158 @@
+package vespa
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates dir/name -> content for each entry in files, creating parent directories
+// as necessary, and returns dir.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// packagedFiles packages dir with opts and returns the contents of every entry in the
+// resulting zip, keyed by name.
+func packagedFiles(t *testing.T, dir string, opts PackageOptions) map[string]string {
+	t.Helper()
+	relDir, err := filepath.Rel(mustGetwd(t), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := ApplicationPackage{Path: relDir}
+	r, err := ap.Package(opts)
+	if err != nil {
+		t.Fatalf("Package() failed: %s", err)
+	}
+	defer r.Close()
+
+	tempZip, err := ioutil.TempFile("", "vespa-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempZip.Name())
+	defer tempZip.Close()
+	if _, err := tempZip.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(tempZip.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+func TestPackageNoDirectivesIsUnchanged(t *testing.T) {
+	services := "<services version=\"1.0\">\n  <admin version=\"2.0\"/>\n</services>\n"
+	dir := writeFiles(t, map[string]string{
+		"services.xml":   services,
+		"schemas/doc.sd": "schema doc {\n  document doc {\n  }\n}\n",
+	})
+	files := packagedFiles(t, dir, PackageOptions{})
+	if files["services.xml"] != services {
+		t.Errorf("services.xml changed:\ngot:  %q\nwant: %q", files["services.xml"], services)
+	}
+	if _, ok := files["schemas/doc.sd"]; !ok {
+		t.Errorf("schemas/doc.sd missing from package")
+	}
+}
+
+func TestPackageResolvesNestedIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"services.xml": "<services version=\"1.0\">\n" +
+			"  <preprocess:include file=\"includes/container.xml\" />\n" +
+			"</services>\n",
+		"includes/container.xml": "<?xml version=\"1.0\"?>\n" +
+			"<container version=\"1.0\">\n" +
+			"  <preprocess:include file=\"search.xml\" />\n" +
+			"</container>\n",
+		"includes/search.xml": "<search/>\n",
+	})
+	files := packagedFiles(t, dir, PackageOptions{})
+	services := files["services.xml"]
+	if !strings.Contains(services, "<container version=\"1.0\">") || !strings.Contains(services, "<search/>") {
+		t.Errorf("services.xml does not contain resolved includes: %s", services)
+	}
+	if _, ok := files["includes/container.xml"]; ok {
+		t.Errorf("includes/container.xml should not be packaged standalone once inlined")
+	}
+	if _, ok := files["includes/search.xml"]; ok {
+		t.Errorf("includes/search.xml should not be packaged standalone once inlined")
+	}
+}
+
+func TestPackageAppliesEnvironmentOverlay(t *testing.T) {
+	services := "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n" +
+		"<services version=\"1.0\">\n" +
+		"  <admin version=\"2.0\" environment=\"prod\">\n" +
+		"    <nodes count=\"3\"/>\n" +
+		"  </admin>\n" +
+		"  <container version=\"1.0\">\n" +
+		"    <search/>\n" +
+		"  </container>\n" +
+		"</services>\n"
+	dir := writeFiles(t, map[string]string{"services.xml": services})
+	files := packagedFiles(t, dir, PackageOptions{Environment: "dev"})
+	got := files["services.xml"]
+	if strings.Contains(got, "<admin ") {
+		t.Errorf("admin element for non-matching environment was not stripped:\n%s", got)
+	}
+	if !strings.Contains(got, "<?xml version=\"1.0\" encoding=\"utf-8\"?>") {
+		t.Errorf("xml prolog was not preserved:\n%s", got)
+	}
+	if !strings.Contains(got, "<container version=\"1.0\">") || !strings.Contains(got, "<search/>") {
+		t.Errorf("unrelated elements were not preserved:\n%s", got)
+	}
+}
+
+func TestPackageSubstitutesPropertiesWithoutEscapingInSchemas(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"services.xml": "<services version=\"1.0\"><container version=\"1.0\"/></services>\n",
+		"schemas/doc.sd": "schema doc {\n  field f type string {\n    indexing: \"${expr}\" | index\n  }\n}\n",
+	})
+	files := packagedFiles(t, dir, PackageOptions{Properties: map[string]string{"expr": "a & b < c"}})
+	want := "schema doc {\n  field f type string {\n    indexing: \"a & b < c\" | index\n  }\n}\n"
+	if files["schemas/doc.sd"] != want {
+		t.Errorf("schemas/doc.sd = %q, want %q", files["schemas/doc.sd"], want)
+	}
+}